@@ -0,0 +1,67 @@
+// Copyright 2015 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License. See the AUTHORS file
+// for names of contributors.
+
+package sql
+
+import "github.com/cockroachdb/cockroach/sql/parser"
+
+// Delete removes the rows from a single table matching n.Where.
+//
+// Privileges: DELETE on table
+func (p *planner) Delete(n *parser.Delete) (planNode, error) {
+	s := &scanNode{txn: p.txn}
+	if err := s.initFrom(p, n.Table); err != nil {
+		return nil, err
+	}
+	if err := s.initWhere(n.Where); err != nil {
+		return nil, err
+	}
+	return &deleteNode{scanNode: s, planner: p}, nil
+}
+
+// deleteNode wraps a scanNode over the rows a DELETE matched: each row is
+// removed as it's consumed, and once the scan is exhausted without error the
+// query cache is invalidated for every column of the table.
+type deleteNode struct {
+	*scanNode
+	planner *planner
+	err     error
+}
+
+func (n *deleteNode) Next() bool {
+	if n.err != nil {
+		return false
+	}
+	if !n.scanNode.Next() {
+		if err := n.scanNode.Err(); err != nil {
+			n.err = err
+		} else {
+			n.planner.invalidateQueryCache(n.scanNode.desc.ID, allColumnIDs(n.scanNode.desc))
+		}
+		return false
+	}
+	if err := n.scanNode.txn.Del(n.scanNode.primaryKey()); err != nil {
+		n.err = err
+		return false
+	}
+	return true
+}
+
+func (n *deleteNode) Err() error {
+	if n.err != nil {
+		return n.err
+	}
+	return n.scanNode.Err()
+}
@@ -0,0 +1,140 @@
+// Copyright 2015 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License. See the AUTHORS file
+// for names of contributors.
+
+package sql
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/cockroachdb/cockroach/sql/parser"
+)
+
+// explainColumns are the columns returned by EXPLAIN SELECT. VERBOSE mode
+// appends one more column showing the computed range bounds per referenced
+// column.
+var explainColumns = []string{
+	"rank", "index_name", "cost", "start_key", "end_key", "covering", "reason",
+}
+
+const explainVerboseColumn = "column_ranges"
+
+// Explain implements EXPLAIN SELECT. Rather than running the wrapped SELECT,
+// it surfaces the same candidate indexes, costs and key ranges that
+// selectIndex computes internally and otherwise only logs behind
+// log.V(2) -- this gives a user filing a slow-query bug something to look at
+// besides turning up the log verbosity.
+//
+// Privileges: the same privileges required by the wrapped statement.
+func (p *planner) Explain(n *parser.Explain) (planNode, error) {
+	sel, ok := n.Statement.(*parser.Select)
+	if !ok {
+		return nil, fmt.Errorf("EXPLAIN is not supported for %T", n.Statement)
+	}
+
+	s := &scanNode{txn: p.txn}
+	if err := s.initFrom(p, sel.From); err != nil {
+		return nil, err
+	}
+	if err := s.initWhere(sel.Where); err != nil {
+		return nil, err
+	}
+	if err := s.initTargets(sel.Exprs); err != nil {
+		return nil, err
+	}
+
+	verbose := n.Mode == parser.ExplainVerbose
+	columns := explainColumns
+	if verbose {
+		columns = append(append([]string{}, explainColumns...), explainVerboseColumn)
+	}
+
+	if s.desc == nil || s.filter == nil {
+		// selectIndex bails out immediately in this case; there's no candidate
+		// set to show.
+		return &rowsNode{columns: columns}, nil
+	}
+
+	rangeInfo := make(qvalueRangeMap)
+	rangeInfo.analyzeExpr(s.filter)
+
+	candidates := indexCandidates(s)
+	for _, c := range candidates {
+		c.analyzeRanges(s, rangeInfo)
+	}
+	sort.Sort(indexInfoByCost(candidates))
+
+	var rangeDesc string
+	if verbose {
+		rangeDesc = describeQvalueRangeMap(s, rangeInfo)
+	}
+
+	rows := make([]parser.DTuple, len(candidates))
+	for i, c := range candidates {
+		row := parser.DTuple{
+			parser.DInt(i),
+			parser.DString(c.index.Name),
+			parser.DFloat(c.cost),
+			parser.DString(fmt.Sprintf("%s", c.makeStartKey())),
+			parser.DString(fmt.Sprintf("%s", c.makeEndKey())),
+			parser.DBool(c.isCoveringIndex(s.qvals)),
+			parser.DString(c.reason),
+		}
+		if verbose {
+			row = append(row, parser.DString(rangeDesc))
+		}
+		rows[i] = row
+	}
+
+	return &rowsNode{columns: columns, rows: rows}, nil
+}
+
+// describeQvalueRangeMap renders the intersected/unioned bounds analyzeExpr
+// computed for each referenced column, e.g. "x < 10 AND x >= 3" (clauses are
+// sorted as strings), so a user can see why the planner picked a particular
+// scan bound.
+func describeQvalueRangeMap(s *scanNode, m qvalueRangeMap) string {
+	var buf []string
+	for colID, r := range m {
+		col := columnName(s.desc, colID)
+		if r.start.datum != nil {
+			buf = append(buf, fmt.Sprintf("%s %s %s", col, r.start.op, r.start.datum))
+		}
+		if r.end.datum != nil {
+			buf = append(buf, fmt.Sprintf("%s %s %s", col, r.end.op, r.end.datum))
+		}
+	}
+	sort.Strings(buf)
+	desc := ""
+	for i, s := range buf {
+		if i > 0 {
+			desc += " AND "
+		}
+		desc += s
+	}
+	return desc
+}
+
+// columnName returns the name of the column with the given ID, or the ID
+// itself (formatted) if the table descriptor doesn't have it, which
+// shouldn't happen but is friendlier than panicking in an EXPLAIN path.
+func columnName(desc *TableDescriptor, colID ColumnID) string {
+	for _, col := range desc.Columns {
+		if col.ID == colID {
+			return col.Name
+		}
+	}
+	return fmt.Sprintf("<column %d>", colID)
+}
@@ -0,0 +1,117 @@
+// Copyright 2015 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License. See the AUTHORS file
+// for names of contributors.
+
+package sql
+
+import (
+	"github.com/cockroachdb/cockroach/proto"
+	"github.com/cockroachdb/cockroach/sql/parser"
+)
+
+// indexJoinBatchSize caps how many primary keys indexJoinNode accumulates
+// from the secondary index scan before issuing them to the primary index as
+// a single batched lookup, rather than one round trip per row.
+const indexJoinBatchSize = 100
+
+// indexJoinNode implements a lookup join between a non-covering secondary
+// index and its table's primary index: it scans the secondary index for
+// matching keys, recovers the primary key columns embedded in each index
+// entry, and issues a batched point-read against the primary index for
+// whatever columns the secondary index doesn't cover.
+//
+// This is the prerequisite for a secondary index to be useful for anything
+// beyond "SELECT *" on tables where it happens to cover every column:
+// without it, indexInfo.isCoveringIndex forced selectIndex to fall back to a
+// full primary scan any time a query referenced a column outside the index.
+type indexJoinNode struct {
+	index    *scanNode // scans the secondary index, yields primary keys
+	indexPos int       // index into index.spans that index is currently armed with
+	table    *scanNode // looks up the remaining columns by primary key, one batch at a time
+	keys     []proto.Key
+	batched  bool // whether table is currently armed with a lookupBatch result
+	err      error
+}
+
+// newIndexJoinNode builds an indexJoinNode on top of index, a scanNode
+// already configured (by selectIndex) to scan a non-covering secondary
+// index. For every row the index scan produces, the join performs a
+// point-read against the table's primary index to fill in the columns the
+// index doesn't cover.
+func newIndexJoinNode(index *scanNode) *indexJoinNode {
+	table := &scanNode{txn: index.txn, desc: index.desc, index: &index.desc.PrimaryIndex}
+	// The table scan must resolve the same columns the original query asked
+	// for -- not every column of the table -- or Columns()/Values() won't
+	// line up with what the SELECT actually targeted.
+	table.initTargetColumns(dependencyColumns(index.qvals, index.filter))
+	return &indexJoinNode{
+		index: index,
+		table: table,
+	}
+}
+
+func (n *indexJoinNode) Columns() []string {
+	return n.table.Columns()
+}
+
+// Next serves rows out of the current primary-index batch lookup until it's
+// exhausted, then refills the batch from the secondary index scan -- across
+// every span in n.index.spans, not just the first -- gathering up to
+// indexJoinBatchSize primary keys before issuing them to the primary index
+// together, rather than one round trip per row.
+func (n *indexJoinNode) Next() bool {
+	if n.err != nil {
+		return false
+	}
+	for {
+		if n.batched {
+			if n.table.Next() {
+				return true
+			}
+			if err := n.table.Err(); err != nil {
+				n.err = err
+				return false
+			}
+			n.batched = false
+		}
+
+		n.keys = n.keys[:0]
+		for len(n.keys) < indexJoinBatchSize && advanceAcrossSpans(n.index, &n.indexPos) {
+			n.keys = append(n.keys, n.index.primaryKey())
+		}
+		if err := n.index.Err(); err != nil {
+			n.err = err
+			return false
+		}
+		if len(n.keys) == 0 {
+			return false
+		}
+		if err := n.table.lookupBatch(n.keys); err != nil {
+			n.err = err
+			return false
+		}
+		n.batched = true
+	}
+}
+
+func (n *indexJoinNode) Values() parser.DTuple {
+	return n.table.Values()
+}
+
+func (n *indexJoinNode) Err() error {
+	if n.err != nil {
+		return n.err
+	}
+	return n.table.Err()
+}
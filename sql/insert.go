@@ -0,0 +1,135 @@
+// Copyright 2015 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License. See the AUTHORS file
+// for names of contributors.
+
+package sql
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/cockroach/proto"
+	"github.com/cockroachdb/cockroach/sql/parser"
+)
+
+// Insert adds new rows to a table.
+//
+// Privileges: INSERT on table
+func (p *planner) Insert(n *parser.Insert) (planNode, error) {
+	desc, err := p.getTableDesc(n.Table)
+	if err != nil {
+		return nil, err
+	}
+	cols := n.Columns
+	if len(cols) == 0 {
+		cols = make([]string, len(desc.Columns))
+		for i, col := range desc.Columns {
+			cols[i] = col.Name
+		}
+	}
+	return &insertNode{n: n, planner: p, desc: desc, cols: cols}, nil
+}
+
+// insertNode writes each row in n.n.Rows in turn. Once every row has landed
+// it invalidates every column of the table.
+type insertNode struct {
+	n       *parser.Insert
+	planner *planner
+	desc    *TableDescriptor
+	cols    []string
+	pos     int
+	err     error
+}
+
+func (n *insertNode) Columns() []string { return nil }
+
+func (n *insertNode) Next() bool {
+	if n.err != nil {
+		return false
+	}
+	if n.pos >= len(n.n.Rows) {
+		if n.pos > 0 {
+			n.planner.invalidateQueryCache(n.desc.ID, allColumnIDs(n.desc))
+		}
+		return false
+	}
+
+	row := n.n.Rows[n.pos]
+	n.pos++
+
+	if len(row.Exprs) != len(n.cols) {
+		n.err = fmt.Errorf("insert has %d columns but %d values", len(n.cols), len(row.Exprs))
+		return false
+	}
+
+	values := make(map[ColumnID]parser.Datum, len(n.cols))
+	for i, colName := range n.cols {
+		col, err := n.desc.FindColumnByName(colName)
+		if err != nil {
+			n.err = err
+			return false
+		}
+		datum, err := parser.EvalExpr(row.Exprs[i])
+		if err != nil {
+			n.err = err
+			return false
+		}
+		if datum, err = convertDatum(col, datum); err != nil {
+			n.err = err
+			return false
+		}
+		values[col.ID] = datum
+	}
+
+	rowKey := proto.Key(MakeIndexKeyPrefix(n.desc.ID, n.desc.PrimaryIndex.ID))
+	for _, colID := range n.desc.PrimaryIndex.ColumnIDs {
+		datum, ok := values[colID]
+		if !ok {
+			n.err = fmt.Errorf("missing value for primary key column %d", colID)
+			return false
+		}
+		var err error
+		if rowKey, err = encodeTableKey(rowKey, datum); err != nil {
+			n.err = err
+			return false
+		}
+	}
+
+	// The primary key entry itself marks that the row exists, independent of
+	// whichever non-key columns are written below.
+	if err := n.planner.txn.Put(rowKey, nil); err != nil {
+		n.err = err
+		return false
+	}
+
+	for i := range n.desc.Columns {
+		col := &n.desc.Columns[i]
+		if n.desc.PrimaryIndex.containsColumnID(col.ID) {
+			continue
+		}
+		datum, ok := values[col.ID]
+		if !ok {
+			continue
+		}
+		if err := putColumn(n.planner.txn, rowKey, col, datum); err != nil {
+			n.err = err
+			return false
+		}
+	}
+
+	return true
+}
+
+func (n *insertNode) Values() parser.DTuple { return nil }
+
+func (n *insertNode) Err() error { return n.err }
@@ -0,0 +1,55 @@
+// Copyright 2015 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License. See the AUTHORS file
+// for names of contributors.
+
+package sql
+
+import "github.com/cockroachdb/cockroach/sql/parser"
+
+// multiSpanScanNode drives a scanNode across more than one disjoint key
+// span in turn -- e.g. the per-value spans "id IN (1, 5, 900)" produces via
+// indexInfo.makeSpans -- instead of the single range spanning from the
+// first span's start to the last span's end, which would otherwise force a
+// scan of everything in between.
+type multiSpanScanNode struct {
+	scan *scanNode
+	pos  int
+}
+
+// newScanPlan returns s as-is when it has at most one span -- the common
+// case, where a plain scanNode is already correct -- and wraps it in a
+// multiSpanScanNode when selectIndex found more than one, so the caller
+// always gets a planNode that iterates every span s.spans describes.
+func newScanPlan(s *scanNode) planNode {
+	if len(s.spans) <= 1 {
+		return s
+	}
+	return &multiSpanScanNode{scan: s}
+}
+
+func (n *multiSpanScanNode) Columns() []string {
+	return n.scan.Columns()
+}
+
+func (n *multiSpanScanNode) Next() bool {
+	return advanceAcrossSpans(n.scan, &n.pos)
+}
+
+func (n *multiSpanScanNode) Values() parser.DTuple {
+	return n.scan.Values()
+}
+
+func (n *multiSpanScanNode) Err() error {
+	return n.scan.Err()
+}
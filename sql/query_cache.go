@@ -0,0 +1,177 @@
+// Copyright 2015 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License. See the AUTHORS file
+// for names of contributors.
+
+package sql
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/cockroachdb/cockroach/proto"
+	"github.com/cockroachdb/cockroach/sql/parser"
+)
+
+// queryCacheDep describes the state a cached query result depends on: the
+// table and columns read while evaluating its target list and filter. A
+// write invalidates the entry when it touches one of these columns.
+//
+// This used to also gate on the key range the query scanned, evicting only
+// entries whose range overlapped the write's. That's unsound for a query
+// that went through indexJoinNode: its scan range is encoded in the
+// secondary index's keyspace, while every mutation's range is computed
+// against the primary index, so the two were never comparable and the
+// overlap check silently passed queries like that through uninvalidated.
+// Gating on (tableID, columnID) alone is coarser -- a write anywhere in the
+// table evicts every entry touching the column instead of just the rows it
+// changed -- but it can't be stale.
+type queryCacheDep struct {
+	tableID ID
+	indexID IndexID
+	columns map[ColumnID]struct{}
+}
+
+// queryCacheKey identifies a cached query: the normalized SQL text of the
+// statement plus an encoding of its bound parameters. Re-running the same
+// parameterized query with different parameter values produces a different
+// key, so each binding is cached independently.
+type queryCacheKey struct {
+	query  string
+	params string
+}
+
+// makeQueryCacheKey builds the cache key for a query, encoding each bound
+// parameter the same way an index key would so that two bindings that
+// compare equal produce the same key.
+func makeQueryCacheKey(query string, params parser.DTuple) queryCacheKey {
+	var buf bytes.Buffer
+	for _, d := range params {
+		key, err := encodeTableKey(nil, d)
+		if err != nil {
+			// A parameter we can't encode as a key still needs to contribute
+			// something to the key so two different unencodable values don't
+			// collide; fall back to its formatted value.
+			fmt.Fprintf(&buf, "%v", d)
+			continue
+		}
+		buf.Write(key)
+	}
+	return queryCacheKey{query: query, params: buf.String()}
+}
+
+type queryCacheEntry struct {
+	columns   []string
+	rows      []parser.DTuple
+	timestamp proto.Timestamp
+	dep       queryCacheDep
+}
+
+type tableColumnKey struct {
+	tableID ID
+	colID   ColumnID
+}
+
+// queryCache memoizes the materialized output of planner.Select, keyed by
+// the normalized query text and its bound parameters. An entry is evicted
+// when a mutation writes a column it depends on (see queryCacheDep).
+//
+// Invalidation is driven by an inverted index from (tableID, columnID) to
+// the cache keys that depend on that column, so a write only has to walk the
+// entries that could plausibly be affected rather than the whole cache.
+type queryCache struct {
+	mu       sync.Mutex
+	entries  map[queryCacheKey]*queryCacheEntry
+	byColumn map[tableColumnKey][]queryCacheKey
+}
+
+func newQueryCache() *queryCache {
+	return &queryCache{
+		entries:  make(map[queryCacheKey]*queryCacheEntry),
+		byColumn: make(map[tableColumnKey][]queryCacheKey),
+	}
+}
+
+// get returns the cached rows for key, if present and still valid as of
+// readTS. An entry cached at timestamp T is a faithful snapshot for any read
+// at or after T (a later write to a column it depends on evicts it), but is
+// not usable for a read strictly before T, which may predate writes the
+// entry already reflects.
+func (c *queryCache) get(key queryCacheKey, readTS proto.Timestamp) ([]string, []parser.DTuple, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || readTS.Less(e.timestamp) {
+		return nil, nil, false
+	}
+	return e.columns, e.rows, true
+}
+
+// put stores rows for key and records dep in the inverted index so a later
+// mutation can find and evict this entry.
+func (c *queryCache) put(key queryCacheKey, columns []string, rows []parser.DTuple, timestamp proto.Timestamp, dep queryCacheDep) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = &queryCacheEntry{columns: columns, rows: rows, timestamp: timestamp, dep: dep}
+	for colID := range dep.columns {
+		tc := tableColumnKey{dep.tableID, colID}
+		c.byColumn[tc] = append(c.byColumn[tc], key)
+	}
+}
+
+// invalidate evicts every cache entry that depends on tableID and one of
+// colIDs. It is called from the mutation statements (INSERT/UPDATE/DELETE)
+// once a write has landed.
+func (c *queryCache) invalidate(tableID ID, colIDs []ColumnID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	evicted := make(map[queryCacheKey]struct{})
+	for _, colID := range colIDs {
+		tc := tableColumnKey{tableID, colID}
+		keys := c.byColumn[tc]
+		for _, key := range keys {
+			if _, ok := evicted[key]; ok {
+				continue
+			}
+			if e, ok := c.entries[key]; ok && e.dep.tableID == tableID {
+				evicted[key] = struct{}{}
+				delete(c.entries, key)
+			}
+		}
+		delete(c.byColumn, tc)
+	}
+}
+
+// invalidateQueryCache evicts any cached SELECT result depending on
+// tableID/colIDs. It's a no-op when no cache is configured. This is the hook
+// the mutation statements (Insert, Update, Delete) call once a write has
+// landed, so a cached result is never served stale.
+func (p *planner) invalidateQueryCache(tableID ID, colIDs []ColumnID) {
+	if p.queryCache == nil {
+		return
+	}
+	p.queryCache.invalidate(tableID, colIDs)
+}
+
+// allColumnIDs returns the IDs of every column in desc. Used by mutations
+// that don't already have a narrower column list to hand invalidateQueryCache
+// (e.g. DELETE, which can affect every column of the rows it removes).
+func allColumnIDs(desc *TableDescriptor) []ColumnID {
+	ids := make([]ColumnID, len(desc.Columns))
+	for i, col := range desc.Columns {
+		ids[i] = col.ID
+	}
+	return ids
+}
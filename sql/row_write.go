@@ -0,0 +1,58 @@
+// Copyright 2015 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License. See the AUTHORS file
+// for names of contributors.
+
+package sql
+
+import (
+	"github.com/cockroachdb/cockroach/proto"
+	"github.com/cockroachdb/cockroach/sql/parser"
+)
+
+// encodeColumnKey returns the key under which a non-primary-key column's
+// value is stored for the row whose primary index key is rowKey: the row's
+// key with the column ID appended. Every column outside the primary index
+// gets its own key/value pair this way, so writing or overwriting one
+// column never touches another, and a row's existence is independently
+// recorded by rowKey itself (see insertNode.Next).
+func encodeColumnKey(rowKey proto.Key, colID ColumnID) (proto.Key, error) {
+	return encodeTableKey(append(proto.Key{}, rowKey...), parser.DInt(colID))
+}
+
+// kvWriter is the subset of *client.Txn that putColumn needs. Defined
+// locally so this file doesn't have to name the concrete transaction type,
+// the same way scanNode's txn field is used elsewhere in this package
+// without being typed out.
+type kvWriter interface {
+	Put(key proto.Key, value []byte) error
+}
+
+// putColumn writes datum as the value of col for the row at rowKey,
+// converting it to col's type first so a column's stored values are always
+// consistent with its descriptor.
+func putColumn(txn kvWriter, rowKey proto.Key, col *ColumnDescriptor, datum parser.Datum) error {
+	datum, err := convertDatum(col, datum)
+	if err != nil {
+		return err
+	}
+	key, err := encodeColumnKey(rowKey, col.ID)
+	if err != nil {
+		return err
+	}
+	value, err := encodeTableKey(nil, datum)
+	if err != nil {
+		return err
+	}
+	return txn.Put(key, []byte(value))
+}
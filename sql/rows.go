@@ -0,0 +1,82 @@
+// Copyright 2015 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License. See the AUTHORS file
+// for names of contributors.
+
+package sql
+
+import "github.com/cockroachdb/cockroach/sql/parser"
+
+// rowsNode is a planNode that serves a fixed, already-materialized set of
+// rows instead of driving a fresh scan. It backs both cached query results
+// and EXPLAIN output, which are each just a precomputed table.
+type rowsNode struct {
+	columns []string
+	rows    []parser.DTuple
+	pos     int
+}
+
+func (n *rowsNode) Columns() []string {
+	return n.columns
+}
+
+func (n *rowsNode) Next() bool {
+	if n.pos >= len(n.rows) {
+		return false
+	}
+	n.pos++
+	return true
+}
+
+func (n *rowsNode) Values() parser.DTuple {
+	return n.rows[n.pos-1]
+}
+
+func (n *rowsNode) Err() error {
+	return nil
+}
+
+// cachingScanNode wraps a scanNode and, once it has been fully consumed,
+// stores the rows it produced in the query cache under key. It is what
+// planner.Select returns in place of a bare scanNode when a cache is
+// configured and the query wasn't already found in it.
+type cachingScanNode struct {
+	*scanNode
+	cache *queryCache
+	key   queryCacheKey
+	rows  []parser.DTuple
+}
+
+// Next advances the wrapped scan, buffering each row it yields. Once the
+// scan is exhausted *without error* the buffered rows are committed to the
+// cache under key, tagged with the dependency set recorded for this scan
+// (s.cacheDep, set by selectIndex or, when the query has no where-clause, by
+// maybeCacheSelect) and the transaction's read timestamp. A scan that
+// stopped early because of an error must not be cached: the buffered rows
+// would be a truncated, incorrect result masquerading as a complete one for
+// every later identical query.
+func (n *cachingScanNode) Next() bool {
+	if !n.scanNode.Next() {
+		if n.scanNode.Err() == nil {
+			n.cache.put(n.key, n.scanNode.Columns(), n.rows, n.scanNode.txn.Proto.Timestamp, n.scanNode.cacheDep)
+		}
+		return false
+	}
+	values := n.scanNode.Values()
+	// scanNode.Values() is only valid until the next call to Next(), so copy
+	// it before buffering.
+	row := make(parser.DTuple, len(values))
+	copy(row, values)
+	n.rows = append(n.rows, row)
+	return true
+}
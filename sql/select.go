@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"strings"
 
 	"github.com/cockroachdb/cockroach/proto"
 	"github.com/cockroachdb/cockroach/sql/parser"
@@ -48,7 +49,59 @@ func (p *planner) Select(n *parser.Select) (planNode, error) {
 	if err := s.initTargets(n.Exprs); err != nil {
 		return nil, err
 	}
-	return p.selectIndex(s)
+	plan, err := p.selectIndex(s)
+	if err != nil {
+		return nil, err
+	}
+	return p.maybeCacheSelect(s, n, plan)
+}
+
+// maybeCacheSelect consults the query cache (if one is configured) for a
+// previous result of this exact query, keyed by its normalized text and
+// bound parameters, that's still valid as of the current read timestamp. If
+// found, it's returned directly in place of plan. Otherwise plan is wrapped
+// so that its rows are recorded into the cache as they're consumed, making
+// them available to the next identical query.
+func (p *planner) maybeCacheSelect(s *scanNode, n *parser.Select, plan planNode) (planNode, error) {
+	if p.queryCache == nil || s.desc == nil {
+		// No cache configured, or no table to key the dependency set off of
+		// (e.g. "SELECT 1").
+		return plan, nil
+	}
+
+	key := makeQueryCacheKey(n.String(), p.params)
+	if columns, rows, ok := p.queryCache.get(key, p.txn.Proto.Timestamp); ok {
+		// The cache entry's row slices are shared with every other hit on this
+		// key; hand back copies so a caller mutating its result (as rowsNode's
+		// own consumers are free to do, the same way subqueryVisitor copies
+		// plan.Values() before retaining it) can't corrupt the cached entry.
+		rowsCopy := make([]parser.DTuple, len(rows))
+		for i, row := range rows {
+			rowCopy := make(parser.DTuple, len(row))
+			copy(rowCopy, row)
+			rowsCopy[i] = rowCopy
+		}
+		return &rowsNode{columns: columns, rows: rowsCopy}, nil
+	}
+
+	sc, ok := plan.(*scanNode)
+	if !ok {
+		// selectIndex wrapped s in an indexJoinNode (non-covering secondary
+		// index) or a multiSpanScanNode (more than one span); only a bare
+		// scanNode is cached, so these plans are returned uncached.
+		return plan, nil
+	}
+	if sc.cacheDep.columns == nil {
+		// selectIndex returns before recording a dependency set when there is
+		// no where-clause; record it here so writes to the table still evict
+		// this entry.
+		sc.cacheDep = queryCacheDep{
+			tableID: sc.desc.ID,
+			indexID: sc.index.ID,
+			columns: dependencyColumns(sc.qvals, sc.filter),
+		}
+	}
+	return &cachingScanNode{scanNode: sc, cache: p.queryCache, key: key}, nil
 }
 
 type subqueryVisitor struct {
@@ -119,28 +172,7 @@ func (p *planner) selectIndex(s *scanNode) (planNode, error) {
 	rangeInfo := make(qvalueRangeMap)
 	rangeInfo.analyzeExpr(s.filter)
 
-	candidates := make([]*indexInfo, 0, len(s.desc.Indexes)+1)
-
-	if s.isSecondaryIndex {
-		// An explicit secondary index was requested. Only add it to the candidate
-		// indexes list.
-		candidates = append(candidates, &indexInfo{
-			desc:  s.desc,
-			index: s.index,
-		})
-	} else {
-		candidates = append(candidates, &indexInfo{
-			desc:  s.desc,
-			index: &s.desc.PrimaryIndex,
-		})
-		for i := range s.desc.Indexes {
-			candidates = append(candidates, &indexInfo{
-				desc:  s.desc,
-				index: &s.desc.Indexes[i],
-			})
-		}
-	}
-
+	candidates := indexCandidates(s)
 	for _, c := range candidates {
 		c.analyzeRanges(s, rangeInfo)
 	}
@@ -156,11 +188,78 @@ func (p *planner) selectIndex(s *scanNode) (planNode, error) {
 
 	// After sorting, candidates[0] contains the best index. Copy its info into
 	// the scanNode.
-	s.index = candidates[0].index
+	best := candidates[0]
+	s.index = best.index
 	s.isSecondaryIndex = (s.index != &s.desc.PrimaryIndex)
-	s.startKey = candidates[0].makeStartKey()
-	s.endKey = candidates[0].makeEndKey()
-	return s, nil
+	s.spans = best.makeSpans(rangeInfo)
+	s.startKey = s.spans[0].start
+	s.endKey = s.spans[len(s.spans)-1].end
+
+	// Record what this scan depends on: the table and every column
+	// referenced by the target list and filter. The query cache uses this,
+	// once the caller materializes s, to know which later writes must
+	// invalidate the cached result. See queryCacheDep for why this isn't
+	// also scoped to the key range scanned.
+	s.cacheDep = queryCacheDep{
+		tableID: s.desc.ID,
+		indexID: s.index.ID,
+		columns: dependencyColumns(s.qvals, s.filter),
+	}
+
+	if s.isSecondaryIndex && !best.isCoveringIndex(s.qvals) {
+		// The chosen index doesn't contain every column the query needs: scan
+		// it for matching keys and join back to the primary index for the rest
+		// (see indexJoinNode). indexJoinNode drives s across every span itself.
+		return newIndexJoinNode(s), nil
+	}
+	return newScanPlan(s), nil
+}
+
+// indexCandidates returns the indexes eligible to satisfy s's scan: either
+// the explicitly requested secondary index, or the primary index plus every
+// secondary index on the table otherwise. Shared by selectIndex and Explain,
+// which both need to price the same candidate set.
+func indexCandidates(s *scanNode) []*indexInfo {
+	if s.isSecondaryIndex {
+		// An explicit secondary index was requested. Only add it to the
+		// candidate indexes list.
+		return []*indexInfo{{desc: s.desc, index: s.index}}
+	}
+
+	candidates := make([]*indexInfo, 0, len(s.desc.Indexes)+1)
+	candidates = append(candidates, &indexInfo{desc: s.desc, index: &s.desc.PrimaryIndex})
+	for i := range s.desc.Indexes {
+		candidates = append(candidates, &indexInfo{desc: s.desc, index: &s.desc.Indexes[i]})
+	}
+	return candidates
+}
+
+// dependencyColumns returns the set of columns read while evaluating a
+// query's target list and filter: the columns already extracted into qvals
+// (the target expressions) plus any qvalue found while walking filter.
+func dependencyColumns(qvals qvalMap, filter parser.Expr) map[ColumnID]struct{} {
+	cols := make(map[ColumnID]struct{}, len(qvals))
+	for colID := range qvals {
+		cols[colID] = struct{}{}
+	}
+	if filter != nil {
+		parser.WalkExpr(&columnDepVisitor{cols: cols}, filter)
+	}
+	return cols
+}
+
+// columnDepVisitor collects the ColumnIDs of every qvalue it visits.
+type columnDepVisitor struct {
+	cols map[ColumnID]struct{}
+}
+
+var _ parser.Visitor = &columnDepVisitor{}
+
+func (v *columnDepVisitor) Visit(expr parser.Expr) parser.Expr {
+	if qval, ok := expr.(*qvalue); ok {
+		v.cols[qval.col.ID] = struct{}{}
+	}
+	return expr
 }
 
 // qvalueInfo contains one end of a value range. Op is required to be either
@@ -269,9 +368,17 @@ func (q *qvalueInfo) union(n qvalueInfo, start bool) {
 // qvalueRange represents the range of values a qvalue may have. start must be
 // less than end. Note that whether the endpoints are inclusive or exclusive is
 // determined by {start,end}.op.
+//
+// inSet additionally records the exact set of values an IN list restricted
+// the qvalue to, sorted in key order. It exists alongside start/end (which
+// are also narrowed to [min(inSet), max(inSet)]) because start/end alone
+// can't express the gaps between the listed values -- indexInfo.makeSpans
+// uses inSet to scan one span per value instead of the single range that
+// would otherwise cover everything in between.
 type qvalueRange struct {
 	start qvalueInfo
 	end   qvalueInfo
+	inSet []parser.Datum
 }
 
 type qvalueRangeMap map[ColumnID]*qvalueRange
@@ -281,8 +388,7 @@ type qvalueRangeMap map[ColumnID]*qvalueRange
 func (m qvalueRangeMap) analyzeExpr(expr parser.Expr) {
 	switch t := expr.(type) {
 	case *parser.NotExpr:
-		// TODO(pmattis): Similar to OR expressions, we can compute the value range
-		// for the expression and then invert the results.
+		m.analyzeNotExpr(t.Expr)
 
 	case *parser.OrExpr:
 		// Conjunctions are handled below (see *parser.AndExpr). Disjunctions are
@@ -359,6 +465,72 @@ func (m qvalueRangeMap) analyzeExpr(expr parser.Expr) {
 	}
 }
 
+// analyzeNotExpr pushes a NOT down through its operand using De Morgan's
+// laws so the existing AndExpr/OrExpr/ComparisonExpr handling in analyzeExpr
+// can take over, inverting comparison operators as it recurses. It leaves
+// the map unchanged for anything it doesn't recognize (e.g. NOT of a
+// function call) rather than guessing at a bound.
+func (m qvalueRangeMap) analyzeNotExpr(expr parser.Expr) {
+	switch t := expr.(type) {
+	case *parser.NotExpr:
+		// "NOT NOT a" -> "a"
+		m.analyzeExpr(t.Expr)
+
+	case *parser.ParenExpr:
+		m.analyzeNotExpr(t.Expr)
+
+	case *parser.AndExpr:
+		// "NOT (a AND b)" -> "NOT a OR NOT b"
+		m.analyzeExpr(&parser.OrExpr{
+			Left:  &parser.NotExpr{Expr: t.Left},
+			Right: &parser.NotExpr{Expr: t.Right},
+		})
+
+	case *parser.OrExpr:
+		// "NOT (a OR b)" -> "NOT a AND NOT b"
+		m.analyzeNotExpr(t.Left)
+		m.analyzeNotExpr(t.Right)
+
+	case *parser.ComparisonExpr:
+		if inverted, ok := invertComparisonOp(t.Operator); ok {
+			m.analyzeComparisonExpr(&parser.ComparisonExpr{
+				Operator: inverted,
+				Left:     t.Left,
+				Right:    t.Right,
+			})
+		}
+		// Operators invertComparisonOp doesn't invert (e.g. LIKE, IN) leave the
+		// map unchanged.
+
+	case *parser.RangeCond:
+		// "NOT (a BETWEEN b AND c)" -> "a NOT BETWEEN b AND c"
+		m.analyzeExpr(&parser.RangeCond{Left: t.Left, From: t.From, To: t.To, Not: !t.Not})
+	}
+}
+
+// invertComparisonOp returns the operator satisfied by exactly the values op
+// is not, i.e. the op' such that "NOT (a op b)" == "a op' b". Only EQ, NE,
+// LT, LE, GT and GE are inverted; any other operator (LIKE, IN, ...) returns
+// ok=false.
+func invertComparisonOp(op parser.ComparisonOp) (inverted parser.ComparisonOp, ok bool) {
+	switch op {
+	case parser.EQ:
+		return parser.NE, true
+	case parser.NE:
+		return parser.EQ, true
+	case parser.LT:
+		return parser.GE, true
+	case parser.LE:
+		return parser.GT, true
+	case parser.GT:
+		return parser.LE, true
+	case parser.GE:
+		return parser.LT, true
+	default:
+		return 0, false
+	}
+}
+
 // analyzeComparisonExpr analyzes the comparison expression, restricting the
 // start and end info for any qvalues found within it.
 func (m qvalueRangeMap) analyzeComparisonExpr(node *parser.ComparisonExpr) {
@@ -367,9 +539,15 @@ func (m qvalueRangeMap) analyzeComparisonExpr(node *parser.ComparisonExpr) {
 	case parser.EQ, parser.LT, parser.LE, parser.GT, parser.GE:
 		break
 
+	case parser.Like, parser.NotLike, parser.SimilarTo:
+		m.analyzeLikeExpr(node, op)
+		return
+
+	case parser.In:
+		m.analyzeInExpr(node)
+		return
+
 	default:
-		// TODO(pmattis): For parser.LIKE we could extract the constant prefix and
-		// treat as a range restriction.
 		return
 	}
 
@@ -445,6 +623,226 @@ func (m qvalueRangeMap) analyzeComparisonExpr(node *parser.ComparisonExpr) {
 	}
 }
 
+// analyzeLikeExpr handles "col LIKE 'pattern'" (and its NOT LIKE / SIMILAR TO
+// variants) by extracting the pattern's constant prefix and feeding it
+// through the same range-intersection path as GE/LT. For "col LIKE
+// 'abc%xyz'" the prefix is "abc", which restricts the column to
+// ['abc', 'abd'): nothing that doesn't start with "abc" can match. A pattern
+// with no constant prefix (e.g. "%abc") yields no bound at all.
+func (m qvalueRangeMap) analyzeLikeExpr(node *parser.ComparisonExpr, op parser.ComparisonOp) {
+	qval, ok := node.Left.(*qvalue)
+	if !ok || !isConst(node.Right) {
+		// Only "qvalue LIKE constant" is useful here; "constant LIKE qvalue"
+		// can't be turned into a range restriction on a column.
+		return
+	}
+
+	if !isBinaryCollatedType(qval.col.Type) {
+		// Non-binary collations don't compare byte-for-byte, so a byte-prefix
+		// range bound isn't sound for them.
+		return
+	}
+
+	pattern, err := parser.EvalExpr(node.Right)
+	if err != nil {
+		return
+	}
+	patternStr, ok := pattern.(parser.DString)
+	if !ok {
+		return
+	}
+
+	prefix, exact, err := extractLikePrefix(string(patternStr), op == parser.SimilarTo)
+	if err != nil || prefix == "" {
+		return
+	}
+
+	if op == parser.NotLike {
+		if exact {
+			// "a NOT LIKE 'abc'" (no wildcards) is just inequality; there's no
+			// single range that captures it, so leave the map unchanged.
+			return
+		}
+		successor, ok := incrementPrefix(prefix)
+		if !ok {
+			return
+		}
+		// "a NOT LIKE 'prefix%'" excludes exactly [prefix, successor), i.e.
+		// "a < prefix OR a >= successor", mirroring how "a NOT BETWEEN b AND c"
+		// is handled above.
+		m.analyzeExpr(&parser.OrExpr{
+			Left: &parser.ComparisonExpr{
+				Operator: parser.LT,
+				Left:     node.Left,
+				Right:    &parser.StrVal{S: prefix},
+			},
+			Right: &parser.ComparisonExpr{
+				Operator: parser.GE,
+				Left:     node.Left,
+				Right:    &parser.StrVal{S: successor},
+			},
+		})
+		return
+	}
+
+	if log.V(2) {
+		log.Infof("analyzeLikeExpr: %s %s %q -> prefix %q", qval.col.Name, op, patternStr, prefix)
+	}
+
+	datum := parser.DString(prefix)
+	r := m.getRange(qval.col.ID)
+	r.start.intersect(qvalueInfo{datum, parser.GE}, true)
+
+	if exact {
+		r.end.intersect(qvalueInfo{datum, parser.LE}, false)
+		return
+	}
+
+	if successor, ok := incrementPrefix(prefix); ok {
+		r.end.intersect(qvalueInfo{parser.DString(successor), parser.LT}, false)
+	}
+	// If there's no successor (the prefix is all 0xff bytes), only the start
+	// restriction applies.
+}
+
+// extractLikePrefix returns the longest constant prefix of a LIKE (or, when
+// similarTo is true, SIMILAR TO) pattern, along with whether the prefix is
+// the entire pattern -- in which case the match reduces to an equality
+// rather than a prefix bound. The LIKE escape character is assumed to be
+// '\'; SIMILAR TO patterns are treated as plain LIKE patterns up to the
+// first regex metacharacter, after which extraction stops rather than risk
+// misreading the pattern.
+func extractLikePrefix(pattern string, similarTo bool) (prefix string, exact bool, err error) {
+	var buf bytes.Buffer
+	for i := 0; i < len(pattern); {
+		c := pattern[i]
+		switch {
+		case c == '\\' && i+1 < len(pattern):
+			buf.WriteByte(pattern[i+1])
+			i += 2
+
+		case !similarTo && (c == '%' || c == '_'):
+			return buf.String(), false, nil
+
+		case similarTo && strings.ContainsRune("%_|*+?(){}[]^$.", rune(c)):
+			return buf.String(), false, nil
+
+		default:
+			buf.WriteByte(c)
+			i++
+		}
+	}
+	return buf.String(), true, nil
+}
+
+// incrementPrefix returns the lexicographically smallest string that is
+// greater than every string having the given prefix, by incrementing the
+// last byte that isn't already 0xff and dropping any trailing 0xff bytes
+// (the same scheme proto.Key.PrefixEnd uses on already-encoded keys). It
+// returns ok=false if the prefix is empty or consists entirely of 0xff
+// bytes, in which case there is no such successor.
+func incrementPrefix(prefix string) (successor string, ok bool) {
+	b := []byte(prefix)
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] == 0xff {
+			b = b[:i]
+			continue
+		}
+		b[i]++
+		return string(b[:i+1]), true
+	}
+	return "", false
+}
+
+// isBinaryCollatedType reports whether a column's type compares byte for
+// byte, which the prefix range bound above relies on. Columns using a
+// locale-aware (non-binary) collation don't, so LIKE prefix extraction must
+// skip them.
+func isBinaryCollatedType(t ColumnType) bool {
+	return t.Collation == "" || t.Collation == "binary"
+}
+
+// analyzeInExpr handles "col IN (v1, v2, ...)" when the left side is a
+// qvalue and every list element is constant. Each value is recorded in the
+// range's inSet so indexInfo.makeSpans can later emit one span per value
+// instead of the single [min(vals), max(vals)] range that start/end alone
+// would produce -- the latter forces a scan of everything in between, which
+// defeats the point for something like "id IN (1, 5, 900)".
+func (m qvalueRangeMap) analyzeInExpr(node *parser.ComparisonExpr) {
+	qval, ok := node.Left.(*qvalue)
+	if !ok {
+		// "(v1, v2) IN col" isn't a form we see for a scalar IN; nothing to do.
+		return
+	}
+	// node.Right is the unevaluated "(v1, v2, ...)" list, not a parser.DTuple
+	// -- DTuple holds already-evaluated Datums, but a list element here can be
+	// any constant expression (e.g. "1+1"), so it still needs isConst/EvalExpr
+	// below.
+	tuple, ok := node.Right.(*parser.Tuple)
+	if !ok {
+		return
+	}
+
+	vals := make([]parser.Datum, 0, len(tuple.Exprs))
+	for _, e := range tuple.Exprs {
+		if !isConst(e) {
+			// Not every element is constant (e.g. "col IN (1, other_col)"); we
+			// can't turn this into a set of key spans.
+			return
+		}
+		datum, err := parser.EvalExpr(e)
+		if err != nil {
+			return
+		}
+		if _, err := convertDatum(qval.col, datum); err != nil {
+			return
+		}
+		vals = append(vals, datum)
+	}
+	if len(vals) == 0 {
+		return
+	}
+
+	r := m.getRange(qval.col.ID)
+	r.inSet = append(r.inSet, vals...)
+	sort.Slice(r.inSet, func(i, j int) bool {
+		return compareDatums(r.inSet[i], r.inSet[j]) < 0
+	})
+	// Successive IN clauses on the same column (or repeated values within one
+	// clause, e.g. "IN (1, 1, 2)") can add the same value to inSet more than
+	// once; collapse runs of equal, now-adjacent values so makeSpans doesn't
+	// emit a duplicate span -- and so a duplicate row -- per repeat.
+	deduped := r.inSet[:1]
+	for _, v := range r.inSet[1:] {
+		if compareDatums(deduped[len(deduped)-1], v) != 0 {
+			deduped = append(deduped, v)
+		}
+	}
+	r.inSet = deduped
+
+	// Also fold the set into the ordinary start/end bounds (min, max) so
+	// composite indexes where this isn't the leading column -- and so can't
+	// use inSet directly, see makeSpans -- still get a usable, if coarser,
+	// range restriction.
+	min, max := r.inSet[0], r.inSet[len(r.inSet)-1]
+	r.start.intersect(qvalueInfo{min, parser.GE}, true)
+	r.end.intersect(qvalueInfo{max, parser.LE}, false)
+}
+
+// compareDatums orders two datums by their encoded key representation, the
+// same ordering indexInfo.makeStartKey/makeEndKey/makeSpans rely on.
+func compareDatums(a, b parser.Datum) int {
+	aKey, err := encodeTableKey(nil, a)
+	if err != nil {
+		panic(err)
+	}
+	bKey, err := encodeTableKey(nil, b)
+	if err != nil {
+		panic(err)
+	}
+	return bytes.Compare(aKey, bKey)
+}
+
 func (m qvalueRangeMap) getRange(colID ColumnID) *qvalueRange {
 	r := m[colID]
 	if r == nil {
@@ -455,22 +853,17 @@ func (m qvalueRangeMap) getRange(colID ColumnID) *qvalueRange {
 }
 
 type indexInfo struct {
-	desc  *TableDescriptor
-	index *IndexDescriptor
-	start []qvalueInfo
-	end   []qvalueInfo
-	cost  float64
+	desc   *TableDescriptor
+	index  *IndexDescriptor
+	start  []qvalueInfo
+	end    []qvalueInfo
+	cost   float64
+	reason string // human-readable explanation of how cost was derived, for EXPLAIN
 }
 
 // analyzeRanges analyzes the scanNode and range map to determine the cost of
 // using the index.
 func (v *indexInfo) analyzeRanges(s *scanNode, m qvalueRangeMap) {
-	if !v.isCoveringIndex(s.qvals) {
-		// TODO(pmattis): Support non-coverying indexes.
-		v.cost = math.MaxFloat64
-		return
-	}
-
 	v.makeStartInfo(m)
 	v.makeEndInfo(m)
 
@@ -490,12 +883,49 @@ func (v *indexInfo) analyzeRanges(s *scanNode, m qvalueRangeMap) {
 		// The index isn't being restricted at all, bump the cost significantly to
 		// make any index which does restrict the keys more desirable.
 		v.cost *= 1000
+		v.reason = "no range constraint on any index column"
 	} else {
 		v.cost *= float64(len(v.index.ColumnIDs)+len(v.index.ColumnIDs)) /
 			float64(len(v.start)+len(v.end))
+		v.reason = fmt.Sprintf("range constraint on %d of %d index column bound(s)",
+			len(v.start)+len(v.end), 2*len(v.index.ColumnIDs))
+	}
+
+	if !v.isCoveringIndex(s.qvals) {
+		// A non-covering secondary index still has to pay for a primary-key
+		// lookup per row it matches, priced at primaryLookupCost times how
+		// selective the scan is (the fraction of the index's columns left
+		// unbound, as a proxy for the fraction of the table's rows the scan
+		// will return). This used to force the cost to infinity, which meant a
+		// secondary index could never win unless it covered every referenced
+		// column -- selectIndex now knows how to join back to the primary index
+		// (see indexJoinNode), so it's no longer disqualifying, just expensive.
+		v.cost += v.selectivity() * primaryLookupCost
+		v.reason += "; non-covering, requires primary index lookup"
 	}
 }
 
+// primaryLookupCost is the cost-model weight given to a single point-read
+// against the primary index, relative to one key scanned within the index
+// being costed. It's deliberately coarse -- the goal is just to make a
+// non-covering index lose to an equally-selective covering one, and to a
+// primary scan once selectivity is poor enough that the per-row lookups
+// dominate.
+const primaryLookupCost = 10
+
+// selectivity estimates the fraction of an index's rows a scan will return,
+// based on how many of the index's columns are actually bound by
+// v.start/v.end. No bound columns means the scan returns every row
+// (selectivity 1); every column bound means close to a single row
+// (selectivity near 0).
+func (v *indexInfo) selectivity() float64 {
+	total := 2 * len(v.index.ColumnIDs)
+	if total == 0 {
+		return 1
+	}
+	return 1 - float64(len(v.start)+len(v.end))/float64(total)
+}
+
 func (v *indexInfo) makeStartInfo(m qvalueRangeMap) {
 	v.start = make([]qvalueInfo, 0, len(v.index.ColumnIDs))
 	for _, colID := range v.index.ColumnIDs {
@@ -522,6 +952,58 @@ func (v *indexInfo) makeEndInfo(m qvalueRangeMap) {
 	}
 }
 
+// span is a single contiguous key range to scan.
+type span struct {
+	start proto.Key
+	end   proto.Key
+}
+
+// makeSpans returns the set of key spans a scan of this index needs to
+// cover the query. When the leading index column has an inSet recorded
+// (from an IN list, see analyzeInExpr) it emits one span per value in that
+// set instead of the single span makeStartKey/makeEndKey would otherwise
+// produce, so e.g. "id IN (1, 5, 900)" scans three small spans rather than
+// the whole range between 1 and 900.
+func (v *indexInfo) makeSpans(m qvalueRangeMap) []span {
+	if len(v.index.ColumnIDs) > 0 {
+		if r := m[v.index.ColumnIDs[0]]; r != nil && len(r.inSet) > 0 {
+			spans := make([]span, len(r.inSet))
+			prefix := proto.Key(MakeIndexKeyPrefix(v.desc.ID, v.index.ID))
+			for i, val := range r.inSet {
+				startKey, err := encodeTableKey(append(proto.Key{}, prefix...), val)
+				if err != nil {
+					panic(err)
+				}
+				spans[i] = span{start: startKey, end: startKey.PrefixEnd()}
+			}
+			return spans
+		}
+	}
+	return []span{{start: v.makeStartKey(), end: v.makeEndKey()}}
+}
+
+// advanceAcrossSpans advances s and, when it's exhausted the span it's
+// currently armed with, rearms it with the next span in s.spans and resumes
+// -- so a caller sees one continuous iteration across every disjoint span
+// instead of stopping after the first. *pos is the index into s.spans that s
+// is currently armed with; advanceAcrossSpans updates it in place.
+func advanceAcrossSpans(s *scanNode, pos *int) bool {
+	for {
+		if s.Next() {
+			return true
+		}
+		if s.Err() != nil {
+			return false
+		}
+		*pos++
+		if *pos >= len(s.spans) {
+			return false
+		}
+		s.startKey = s.spans[*pos].start
+		s.endKey = s.spans[*pos].end
+	}
+}
+
 func (v *indexInfo) makeStartKey() proto.Key {
 	key := proto.Key(MakeIndexKeyPrefix(v.desc.ID, v.index.ID))
 	for _, e := range v.start {
@@ -0,0 +1,67 @@
+// Copyright 2015 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License. See the AUTHORS file
+// for names of contributors.
+
+package sql
+
+import "testing"
+
+func TestExtractLikePrefix(t *testing.T) {
+	testCases := []struct {
+		pattern   string
+		similarTo bool
+		prefix    string
+		exact     bool
+	}{
+		{`abc`, false, `abc`, true},
+		{`abc%`, false, `abc`, false},
+		{`abc_def`, false, `abc`, false},
+		{`%abc`, false, ``, false},
+		{`ab\%c`, false, `ab%c`, true},
+		{`abc`, true, `abc`, true},
+		{`abc.*`, true, `abc`, false},
+		{`abc|def`, true, `abc`, false},
+		{`abc[0-9]`, true, `abc`, false},
+	}
+	for _, tc := range testCases {
+		prefix, exact, err := extractLikePrefix(tc.pattern, tc.similarTo)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", tc.pattern, err)
+		}
+		if prefix != tc.prefix || exact != tc.exact {
+			t.Errorf("%q: got prefix %q exact %v, want prefix %q exact %v",
+				tc.pattern, prefix, exact, tc.prefix, tc.exact)
+		}
+	}
+}
+
+func TestIncrementPrefix(t *testing.T) {
+	testCases := []struct {
+		prefix    string
+		successor string
+		ok        bool
+	}{
+		{"abc", "abd", true},
+		{"ab\xff", "ac", true},
+		{"\xff\xff", "", false},
+		{"", "", false},
+	}
+	for _, tc := range testCases {
+		successor, ok := incrementPrefix(tc.prefix)
+		if ok != tc.ok || successor != tc.successor {
+			t.Errorf("incrementPrefix(%q) = (%q, %v), want (%q, %v)",
+				tc.prefix, successor, ok, tc.successor, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2015 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License. See the AUTHORS file
+// for names of contributors.
+
+package sql
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/cockroach/sql/parser"
+)
+
+// updateAssignment pairs a target column with the expression to evaluate
+// and store into it, resolved once up front in Update rather than re-looked
+// up for every row.
+type updateAssignment struct {
+	col  *ColumnDescriptor
+	expr parser.Expr
+}
+
+// Update rewrites the columns named in n.Exprs for every row matching
+// n.Where.
+//
+// Privileges: UPDATE on table
+func (p *planner) Update(n *parser.Update) (planNode, error) {
+	s := &scanNode{txn: p.txn}
+	if err := s.initFrom(p, n.Table); err != nil {
+		return nil, err
+	}
+	if err := s.initWhere(n.Where); err != nil {
+		return nil, err
+	}
+
+	assignments := make([]updateAssignment, 0, len(n.Exprs))
+	colIDs := make([]ColumnID, 0, len(n.Exprs))
+	for _, expr := range n.Exprs {
+		if len(expr.Names) != 1 {
+			return nil, fmt.Errorf("UPDATE does not support multiple-column tuple assignment")
+		}
+		col, err := s.desc.FindColumnByName(expr.Names[0])
+		if err != nil {
+			return nil, err
+		}
+		if s.desc.PrimaryIndex.containsColumnID(col.ID) {
+			return nil, fmt.Errorf("cannot update primary key column %q", col.Name)
+		}
+		assignments = append(assignments, updateAssignment{col: col, expr: expr.Expr})
+		colIDs = append(colIDs, col.ID)
+	}
+
+	return &updateNode{scanNode: s, planner: p, assignments: assignments, colIDs: colIDs}, nil
+}
+
+// updateNode wraps a scanNode over the rows an UPDATE matched: each row has
+// its assignments evaluated and written as it's consumed, and once the scan
+// is exhausted without error the query cache is invalidated for the columns
+// the statement wrote.
+type updateNode struct {
+	*scanNode
+	planner     *planner
+	assignments []updateAssignment
+	colIDs      []ColumnID
+	err         error
+}
+
+func (n *updateNode) Next() bool {
+	if n.err != nil {
+		return false
+	}
+	if !n.scanNode.Next() {
+		if err := n.scanNode.Err(); err != nil {
+			n.err = err
+		} else {
+			n.planner.invalidateQueryCache(n.scanNode.desc.ID, n.colIDs)
+		}
+		return false
+	}
+
+	rowKey := n.scanNode.primaryKey()
+	for _, a := range n.assignments {
+		datum, err := parser.EvalExpr(a.expr)
+		if err != nil {
+			n.err = err
+			return false
+		}
+		if err := putColumn(n.scanNode.txn, rowKey, a.col, datum); err != nil {
+			n.err = err
+			return false
+		}
+	}
+	return true
+}
+
+func (n *updateNode) Err() error {
+	if n.err != nil {
+		return n.err
+	}
+	return n.scanNode.Err()
+}